@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	caMode               = flag.Bool("ca-mode", false, "Emit cert-authority entries (for TrustedUserCAKeys / authorized_keys CA trust) instead of plain authorized_keys lines")
+	caRestrictPrincipals = flag.Bool("ca-restrict-principals", false, "Restrict each cert-authority entry to the requested username via a principals= option")
+)
+
+// wrapCertAuthority rewrites each public key line in body as a
+// cert-authority entry, so sshd will trust certificates signed by that
+// key instead of the key itself. When restrictPrincipals is set, each
+// entry is additionally scoped to principal via a principals= option, so
+// the CA is trusted only to vouch for the requested username.
+func wrapCertAuthority(body []byte, principal string, restrictPrincipals bool) []byte {
+	var out bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		options := "cert-authority"
+		if restrictPrincipals {
+			options = fmt.Sprintf("cert-authority,principals=%q", principal)
+		}
+
+		// authlog wraps each key with a leading command="..." option.
+		// sshd only parses options up to the first unquoted space, so
+		// cert-authority must join that existing option list with a
+		// comma rather than being prefixed as a separate space-
+		// separated token, or it gets misread as the key type.
+		if strings.HasPrefix(line, `command="`) {
+			fmt.Fprintf(&out, "%s,%s\n", options, line)
+			continue
+		}
+
+		fmt.Fprintf(&out, "%s %s\n", options, line)
+	}
+
+	return out.Bytes()
+}
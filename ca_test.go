@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestWrapCertAuthority(t *testing.T) {
+	body := "ssh-rsa AAAAB3NzaC1yc2EA alice-ca\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5 ops-ca\n"
+
+	got := string(wrapCertAuthority([]byte(body), "alice", false))
+
+	want := "cert-authority ssh-rsa AAAAB3NzaC1yc2EA alice-ca\n" +
+		"cert-authority ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 ops-ca\n"
+	if got != want {
+		t.Errorf("Got:\n%s\nWanted:\n%s", got, want)
+	}
+}
+
+func TestWrapCertAuthorityRestrictPrincipals(t *testing.T) {
+	body := "ssh-rsa AAAAB3NzaC1yc2EA alice-ca\n"
+
+	got := string(wrapCertAuthority([]byte(body), "alice", true))
+
+	want := `cert-authority,principals="alice" ssh-rsa AAAAB3NzaC1yc2EA alice-ca` + "\n"
+	if got != want {
+		t.Errorf("Got:\n%s\nWanted:\n%s", got, want)
+	}
+}
+
+func TestWrapCertAuthorityWithAuthlog(t *testing.T) {
+	body := `command="/usr/local/bin/sshlogger.sh alice s3://bucket ca" ssh-rsa AAAAB3NzaC1yc2EA alice-ca` + "\n"
+
+	got := string(wrapCertAuthority([]byte(body), "alice", false))
+
+	want := `cert-authority,command="/usr/local/bin/sshlogger.sh alice s3://bucket ca" ssh-rsa AAAAB3NzaC1yc2EA alice-ca` + "\n"
+	if got != want {
+		t.Errorf("Got:\n%s\nWanted:\n%s", got, want)
+	}
+}
+
+func TestWrapCertAuthoritySkipsBlankLines(t *testing.T) {
+	body := "ssh-rsa AAAAB3NzaC1yc2EA alice-ca\n\n"
+
+	got := string(wrapCertAuthority([]byte(body), "alice", false))
+
+	want := "cert-authority ssh-rsa AAAAB3NzaC1yc2EA alice-ca\n"
+	if got != want {
+		t.Errorf("Got:\n%s\nWanted:\n%s", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	cacheDir = flag.String("cache-dir", "", "`directory` to cache authorized_keys output in, eg. /var/cache/sshauth. Caching is disabled unless set; a fresh cache entry is served without checking the sources at all, so -cache-ttl is also a bound on how quickly a revoked key stops working")
+	cacheTTL = flag.Duration("cache-ttl", 5*time.Minute, "How long a cached authorized_keys lookup stays fresh")
+)
+
+// cache persists the concatenated authorized_keys output for a user to
+// local disk. This lets sshauth keep serving logins from the last known
+// good lookup when its key sources (eg. S3) are unreachable, which matters
+// because sshauth sits on the SSH login critical path.
+type cache struct {
+	dir string
+	ttl time.Duration
+}
+
+func newCache() *cache {
+	return &cache{dir: *cacheDir, ttl: *cacheTTL}
+}
+
+// enabled reports whether caching was configured.
+func (c *cache) enabled() bool {
+	return c.dir != ""
+}
+
+// path returns the cache file for the given sources and user.
+func (c *cache) path(sources []string, user string) string {
+	sum := sha256.Sum256([]byte(strings.Join(sources, "\n") + "\n" + user))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// fresh returns the cached output for sources/user if it exists and was
+// written within ttl.
+func (c *cache) fresh(sources []string, user string) ([]byte, bool) {
+	path := c.path(sources, user)
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(stat.ModTime()) > c.ttl {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+// stale returns the cached output for sources/user regardless of its age,
+// for use as a fallback when a live fetch fails.
+func (c *cache) stale(sources []string, user string) ([]byte, bool) {
+	body, err := ioutil.ReadFile(c.path(sources, user))
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// store atomically writes body as the cached output for sources/user.
+func (c *cache) store(sources []string, user string, body []byte) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, ".sshauth-cache-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(sources, user))
+}
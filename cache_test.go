@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheFreshHit(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sshauth-cache")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &cache{dir: dir, ttl: time.Minute}
+	sources := []string{"s3://bucket/prefix"}
+
+	if err := c.store(sources, "alice", []byte("key1\n")); err != nil {
+		t.Fatalf("store returned error: %v", err)
+	}
+
+	body, ok := c.fresh(sources, "alice")
+	if !ok {
+		t.Fatalf("expected a fresh cache hit")
+	}
+	if string(body) != "key1\n" {
+		t.Errorf("Got: %q, Wanted: %q", body, "key1\n")
+	}
+}
+
+func TestCacheExpiredIsNotFresh(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sshauth-cache")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &cache{dir: dir, ttl: time.Minute}
+	sources := []string{"s3://bucket/prefix"}
+
+	if err := c.store(sources, "alice", []byte("key1\n")); err != nil {
+		t.Fatalf("store returned error: %v", err)
+	}
+	if err := os.Chtimes(c.path(sources, "alice"), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Unable to backdate cache file: %v", err)
+	}
+
+	if _, ok := c.fresh(sources, "alice"); ok {
+		t.Errorf("expected an expired cache entry to not be fresh")
+	}
+
+	// An expired entry is still available as a stale fallback.
+	body, ok := c.stale(sources, "alice")
+	if !ok {
+		t.Fatalf("expected the expired entry to still be usable as a stale fallback")
+	}
+	if string(body) != "key1\n" {
+		t.Errorf("Got: %q, Wanted: %q", body, "key1\n")
+	}
+}
+
+func TestCacheStaleOnFetchError(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sshauth-cache")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &cache{dir: dir, ttl: time.Minute}
+	sources := []string{"s3://bucket/prefix"}
+
+	if err := c.store(sources, "alice", []byte("key1\n")); err != nil {
+		t.Fatalf("store returned error: %v", err)
+	}
+	if err := os.Chtimes(c.path(sources, "alice"), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Unable to backdate cache file: %v", err)
+	}
+
+	// Simulate the fetch-failed path that printAuthorizedKeys takes: no
+	// fresh entry, but a stale one exists and is used instead of failing.
+	if _, ok := c.fresh(sources, "alice"); ok {
+		t.Fatalf("test setup: expected cache entry to be stale, not fresh")
+	}
+
+	body, ok := c.stale(sources, "alice")
+	if !ok {
+		t.Fatalf("expected a stale cache entry to serve as fallback on fetch error")
+	}
+	if string(body) != "key1\n" {
+		t.Errorf("Got: %q, Wanted: %q", body, "key1\n")
+	}
+}
+
+func TestCacheMissWithoutStaleEntry(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sshauth-cache")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &cache{dir: dir, ttl: time.Minute}
+	sources := []string{"s3://bucket/prefix"}
+
+	if _, ok := c.fresh(sources, "alice"); ok {
+		t.Errorf("expected no fresh entry for an empty cache")
+	}
+	if _, ok := c.stale(sources, "alice"); ok {
+		t.Errorf("expected no stale entry for an empty cache")
+	}
+}
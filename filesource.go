@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterKeySource("file", newFileKeySource)
+}
+
+// fileKeySource reads authorized keys directly from the local filesystem,
+// eg. a directory of per-user key files laid down by configuration
+// management. It takes its path straight from the -source URI and needs
+// no further setup.
+type fileKeySource struct{}
+
+// newFileKeySource builds a fileKeySource for a file:///path source URI.
+// The path to read from is taken from the URI's path component.
+func newFileKeySource(u *url.URL) (KeySource, string, error) {
+	if u.Path == "" {
+		return nil, "", fmt.Errorf("file source %q is missing a path", u.String())
+	}
+
+	return fileKeySource{}, u.Path, nil
+}
+
+// List returns the files found under prefix. If prefix names a file
+// rather than a directory, it is returned as the single path to Get,
+// matching a "one file per user" layout; a missing prefix yields no keys.
+func (fileKeySource) List(prefix string) ([]string, error) {
+	entries, err := ioutil.ReadDir(prefix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if stat, statErr := os.Stat(prefix); statErr == nil && !stat.IsDir() {
+			return []string{prefix}, nil
+		}
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		paths = append(paths, filepath.Join(prefix, entry.Name()))
+	}
+
+	return paths, nil
+}
+
+// Get opens the file at path for reading. A missing file is not an
+// error: it means the user simply has no key there, the same as an empty
+// directory listing.
+func (fileKeySource) Get(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ioutil.NopCloser(strings.NewReader("")), nil
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
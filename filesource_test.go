@@ -0,0 +1,139 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileKeySourceListDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshauth-filesource")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	userDir := filepath.Join(dir, "alice")
+	if err := os.Mkdir(userDir, 0700); err != nil {
+		t.Fatalf("Unable to create user dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(userDir, "key1"), []byte("ssh-ed25519 AAAA... alice-laptop\n"), 0600); err != nil {
+		t.Fatalf("Unable to write key file: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(userDir, "key2"), []byte("ssh-ed25519 BBBB... alice-phone\n"), 0600); err != nil {
+		t.Fatalf("Unable to write key file: %v", err)
+	}
+
+	src := fileKeySource{}
+
+	got, err := src.List(userDir)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+
+	want := []string{filepath.Join(userDir, "key1"), filepath.Join(userDir, "key2")}
+	if len(got) != len(want) {
+		t.Fatalf("Got: %v, Wanted: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Got: %v, Wanted: %v", got, want)
+		}
+	}
+}
+
+func TestFileKeySourceListSingleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshauth-filesource")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "bob")
+	if err := ioutil.WriteFile(keyFile, []byte("ssh-ed25519 CCCC... bob\n"), 0600); err != nil {
+		t.Fatalf("Unable to write key file: %v", err)
+	}
+
+	src := fileKeySource{}
+
+	got, err := src.List(keyFile)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(got) != 1 || got[0] != keyFile {
+		t.Errorf("Got: %v, Wanted: %v", got, []string{keyFile})
+	}
+}
+
+func TestFileKeySourceListMissingIsEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshauth-filesource")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := fileKeySource{}
+
+	got, err := src.List(filepath.Join(dir, "carol"))
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no paths for a missing user, got: %v", got)
+	}
+}
+
+func TestFileKeySourceGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshauth-filesource")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "bob")
+	want := "ssh-ed25519 CCCC... bob\n"
+	if err := ioutil.WriteFile(keyFile, []byte(want), 0600); err != nil {
+		t.Fatalf("Unable to write key file: %v", err)
+	}
+
+	src := fileKeySource{}
+
+	rc, err := src.Get(keyFile)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read body: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+}
+
+func TestFileKeySourceGetMissingIsEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshauth-filesource")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := fileKeySource{}
+
+	rc, err := src.Get(filepath.Join(dir, "carol"))
+	if err != nil {
+		t.Fatalf("Get returned error for a missing file: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read body: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no key data for a missing file, got: %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// KeySource provides authorized keys for a user from some backing store,
+// addressed by a URI such as s3://bucket/prefix, vault:///prefix, or
+// file:///etc/sshauth.d/. Backends register themselves under a URL scheme
+// via RegisterKeySource, the same well-known-filesystem style pattern used
+// by cashier's wkfs; further schemes (eg. gs://) can be added the same way.
+type KeySource interface {
+	// List returns the full paths of the keys available under prefix.
+	List(prefix string) ([]string, error)
+	// Get opens the key at path for reading. The caller must close it.
+	Get(path string) (io.ReadCloser, error)
+}
+
+// KeySourceFactory builds a KeySource from a parsed -source URI and
+// returns the prefix to list within it, derived from the URI's host
+// and/or path.
+type KeySourceFactory func(u *url.URL) (source KeySource, prefix string, err error)
+
+var keySourceFactories = map[string]KeySourceFactory{}
+
+// RegisterKeySource registers a KeySource factory under the given URL
+// scheme. It is meant to be called from an init() function in the
+// backend's own file.
+func RegisterKeySource(scheme string, factory KeySourceFactory) {
+	keySourceFactories[scheme] = factory
+}
+
+// newKeySource parses a -source URI and constructs the matching KeySource.
+// A URI given without a scheme (eg. "mybucket/prefix") is treated as s3://
+// to keep the historical -bucket/-key behavior as the default.
+func newKeySource(rawSource string) (KeySource, string, error) {
+	if !strings.Contains(rawSource, "://") {
+		rawSource = "s3://" + rawSource
+	}
+
+	u, err := url.Parse(rawSource)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to parse source %q: %v", rawSource, err)
+	}
+
+	factory, ok := keySourceFactories[u.Scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("no key source registered for scheme %q", u.Scheme)
+	}
+
+	return factory(u)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"testing"
+)
+
+type stubKeySource struct{}
+
+func (stubKeySource) List(prefix string) ([]string, error)   { return nil, nil }
+func (stubKeySource) Get(path string) (io.ReadCloser, error) { return nil, nil }
+
+func TestNewKeySourceDefaultsToS3(t *testing.T) {
+	// Swap out the s3 factory just for this test so we don't need real
+	// AWS credentials to exercise the "no scheme" default.
+	real := keySourceFactories["s3"]
+	keySourceFactories["s3"] = func(u *url.URL) (KeySource, string, error) {
+		return stubKeySource{}, u.Path, nil
+	}
+	defer func() { keySourceFactories["s3"] = real }()
+
+	src, prefix, err := newKeySource("mybucket/prefix")
+	if err != nil {
+		t.Fatalf("newKeySource returned error: %v", err)
+	}
+	if _, ok := src.(stubKeySource); !ok {
+		t.Errorf("expected the s3 backend to be used by default")
+	}
+	if prefix != "/prefix" {
+		t.Errorf("Got prefix: %v, Wanted: %v", prefix, "/prefix")
+	}
+}
+
+func TestNewKeySourceUnknownScheme(t *testing.T) {
+	_, _, err := newKeySource("nosuchscheme://bucket/prefix")
+	if err == nil {
+		t.Errorf("expected an error for an unregistered scheme")
+	}
+}
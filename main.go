@@ -12,12 +12,9 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"strings"
 	"syscall"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/defaults"
-	"github.com/aws/aws-sdk-go/service/s3"
+	"time"
 )
 
 //go:generate go run tools/include.go
@@ -29,15 +26,20 @@ const (
 	logheader    = "command=\"%s %s %s %s\" "
 )
 
-type s3er interface {
-	GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error)
-	ListObjectsPages(*s3.ListObjectsInput, func(p *s3.ListObjectsOutput, lastPage bool) (shouldContinue bool)) error
+// sourceList collects repeated -source flags into a slice.
+type sourceList []string
+
+func (s *sourceList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *sourceList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 var (
-	bucket         = flag.String("bucket", "", "S3 bucket `name`")
-	key            = flag.String("key", "", "S3 bucket `prefix`")
-	region         = flag.String("region", "", "AWS `region`, eg. eu-west-1")
+	sources        sourceList
 	authlog        = flag.String("authlog", "", "Set `path` to sshlogger script")
 	syslogEnabled  = flag.Bool("syslog", false, "Enable logging via syslog")
 	printSSHLogger = flag.Bool("sshlogger", false, "Print sshlogger script to stdout and exit")
@@ -46,20 +48,61 @@ var (
 
 	info  = log.New(ioutil.Discard, "", 0)
 	debug = log.New(ioutil.Discard, " * ", 0)
-
-	svc s3er
 )
 
+func init() {
+	flag.Var(&sources, "source", "Key source `URI`, eg. s3://bucket/prefix. May be given multiple times to combine sources")
+}
+
 func usage() {
 	fmt.Fprintln(os.Stderr,
-		`Usage: sshauth [OPTIONS] -bucket <name> [-key <prefix>] <username>
-Read authorized keys from S3 to be used with AuthorizedKeysCommand in sshd
+		`Usage: sshauth [OPTIONS] -source <uri> [-source <uri> ...] <username>
+Read authorized keys from one or more key sources to be used with
+AuthorizedKeysCommand in sshd
 `)
 
 	flag.PrintDefaults()
 
 	fmt.Fprintln(os.Stderr, `
-Note: The final S3 path will be: s3://bucket/key/username
+Note: The final path looked up in each source will be: <source>/username
+
+SOURCES
+-source takes a URI naming the backend and location to read from, eg.
+  s3://bucket/prefix       Amazon S3 (also the default when no scheme is given)
+  vault://                 HashiCorp Vault, see the -vault-* flags
+  file:///path             Local filesystem, eg. /etc/sshauth.d/
+-source may be repeated; the authorized_keys found in every source are
+concatenated, in the order given.
+
+S3 credentials default to the usual AWS credential chain. Use -anonymous
+for buckets with a public-read bucket policy, -profile for a named
+shared-credentials profile, or -assume-role-arn (with an optional
+-assume-role-external-id) to read from a bucket in another account via
+STS AssumeRole.
+
+CACHING
+Set -cache-dir to cache the authorized_keys output for each user locally;
+caching is disabled by default. A fresh cache entry (within -cache-ttl) is
+served without touching any source, so a key removed from a source can
+still authenticate for up to -cache-ttl after the cache was last written
+-- choose -cache-ttl with that revocation window in mind. If a source
+fails, the last known-good cache entry is served regardless of age (and a
+warning logged), so a transient outage does not break SSH logins.
+
+METRICS
+Prometheus metrics can be pushed to a Pushgateway on exit with
+-pushgateway. There is no scrape/-metrics-listen mode: sshd waits for this
+per-connection process to exit before using its output, so a listener
+here would never be up long enough for a scraper to hit it; a pull-based
+endpoint would need a separate long-running daemon, which is out of scope.
+
+CA MODE
+With -ca-mode, each key fetched from the configured sources is emitted as
+a cert-authority entry instead of a plain authorized_keys line, trusting
+certificates signed by that key rather than the key itself. Point -source
+at a prefix holding one or more CA public keys (eg. s3://bucket/ca-keys)
+to concatenate multiple CAs. Add -ca-restrict-principals to scope every
+CA to the requested username via a principals= option.
 
 CONFIGURATION
 Default configuration is done by defining flags in /etc/sshauth/sshauth.conf
@@ -68,15 +111,15 @@ That is, in the same way as done on the command line.
 AUTHLOG
 When the authlog feature is enabled sshauth will inject a command option for
 each authorized key. The command will be the one specified by the -authlog flag.
-The command is provided three commmand line parameters: ´user´, ´bucket´, and
-´key´. In addition, the command originally supplied by the client is available
+The command is provided three commmand line parameters: ´user´, ´source´, and
+´path´. In addition, the command originally supplied by the client is available
 in the SSH_ORIGINAL_COMMAND environment variable.
 
 Create sshlogger.sh
 ´sshauth -sshlogger > /usr/local/bin/sshlogger.sh´
 
 Running sshauth with authlog enabled
-´sshauth -bucket myBucket -authlog /usr/local/bin/sshlogger.sh myUser´
+´sshauth -source s3://myBucket -authlog /usr/local/bin/sshlogger.sh myUser´
 `)
 }
 
@@ -120,26 +163,21 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *bucket == "" {
-		usageError("Error: S3 bucket is required")
+	if len(sources) == 0 {
+		usageError("Error: At least one -source is required")
 	}
 
 	if flag.NArg() != 1 {
 		usageError("Error: Username is required")
 	}
 
-	if *region != "" {
-		defaults.DefaultConfig = defaults.DefaultConfig.WithRegion(*region).WithMaxRetries(10)
-		debug.Printf("Setting region: %s", *region)
-	}
-
-	svc = s3.New(nil)
-
 	user := flag.Arg(0)
 
 	go listenOnSigpipe()
 
-	printAuthorizedKeys(*bucket, *key, user)
+	printAuthorizedKeys(sources, user)
+
+	maybePushMetrics(user)
 }
 
 func mustEnableSyslog(logger *log.Logger, p syslog.Priority, tag string) {
@@ -157,90 +195,167 @@ func listenOnSigpipe() {
 	debug.Printf("recived SIGPIPE signal: ignoring")
 }
 
-// readAuthorizedKey reads the authorized keys from S3
-func readAuthorizedKey(bucket, key string, authorizedKeys chan io.Reader) {
-	debug.Printf("Reading authorized key from bucket: %s, path: %s", bucket, key)
-	params := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	}
-	resp, err := svc.GetObject(params)
+// keyResult is the outcome of fetching a single authorized key: the data
+// to emit, and the error (if any) encountered while fetching it.
+type keyResult struct {
+	data io.Reader
+	err  error
+}
+
+// readAuthorizedKey reads a single authorized key from src and, if
+// enabled, wraps it with the authlog command option before handing the
+// result off on results.
+func readAuthorizedKey(src KeySource, sourceURI, key, user string, results chan keyResult) {
+	debug.Printf("Reading authorized key from source: %s, path: %s", sourceURI, key)
 
+	rc, err := src.Get(key)
 	if err != nil {
-		switch e := err.(type) {
-		case awserr.Error:
-			info.Printf("Unable to get authorized key from S3: %s: %s", e.Code(), e.Message())
-		default:
-			info.Printf("Unable to get authorized key from S3: %v", e)
-		}
-		authorizedKeys <- bytes.NewReader([]byte{})
+		info.Printf("Unable to get authorized key from %s: %v", sourceURI, err)
+		results <- keyResult{data: bytes.NewReader([]byte{}), err: err}
 		return
 	}
+	defer rc.Close()
 
 	// Make sure that the the string ends with a new line
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(rc)
 	if err != nil {
 		info.Printf("Unable to convert authorized key to byte array: %v", err)
+		results <- keyResult{data: bytes.NewReader([]byte{}), err: err}
+		return
 	}
 	if !bytes.HasSuffix(body, []byte("\n")) {
 		body = append(body, []byte("\n")...)
 	}
 
 	if *authlog != "" {
-		outbuf := bytes.NewBufferString(fmt.Sprintf(logheader, *authlog, path.Base(key), bucket, key))
-		outbuf.Read(body)
-		authorizedKeys <- outbuf
+		outbuf := bytes.NewBufferString(fmt.Sprintf(logheader, *authlog, user, sourceURI, key))
+		outbuf.Write(body)
+		results <- keyResult{data: outbuf}
 		return
 	}
 
-	authorizedKeys <- bytes.NewBuffer(body)
+	results <- keyResult{data: bytes.NewBuffer(body)}
 }
 
-// printAuthorizedKeys for specified bucket, prefix (key) and user
-// the used path will be bucket/prefix/user/*
-func printAuthorizedKeys(bucket, authorizedKeysPath, user string) {
-	authorizedKeys := make(chan io.Reader, 5)
+// fetchAuthorizedKeys lists and reads the authorized keys for user from
+// every configured source, in order, and returns them concatenated. It
+// keeps going after a source fails so a single bad source doesn't hide
+// keys from the others, but returns the first error encountered so the
+// caller can fall back to a cached copy if one is available.
+func fetchAuthorizedKeys(sourceURIs []string, user string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	var firstErr error
+
+	results := make(chan keyResult, 5)
+
+	for _, sourceURI := range sourceURIs {
+		src, prefix, err := newKeySource(sourceURI)
+		if err != nil {
+			info.Printf("Unable to initialize key source %s: %v", sourceURI, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
 
-	authorizedKeysPath = path.Join(authorizedKeysPath, user)
-	debug.Printf("Listing authorized keys from bucket: %s, path: %s", bucket, authorizedKeysPath)
+		userPath := path.Join(prefix, user)
+		debug.Printf("Listing authorized keys from source: %s, path: %s", sourceURI, userPath)
 
-	params := &s3.ListObjectsInput{
-		Bucket: aws.String(bucket),
-		Prefix: aws.String(authorizedKeysPath),
-	}
-
-	err := svc.ListObjectsPages(params, func(resp *s3.ListObjectsOutput, lastPage bool) (shouldContinue bool) {
-		for _, content := range resp.Contents {
-			// If it's a root key skip reading it
-			if *content.Key == authorizedKeysPath {
-				authorizedKeys <- bytes.NewReader([]byte{})
-				continue
+		keys, err := src.List(userPath)
+		if err != nil {
+			info.Printf("Unable to list authorized keys from %s: %v", sourceURI, err)
+			if firstErr == nil {
+				firstErr = err
 			}
-			go readAuthorizedKey(bucket, *content.Key, authorizedKeys)
+			continue
+		}
+
+		for _, key := range keys {
+			go readAuthorizedKey(src, sourceURI, key, user, results)
 		}
 
-		for range resp.Contents {
-			_, err := io.Copy(os.Stdout, <-authorizedKeys)
-			if err != nil {
-				if err == syscall.EPIPE {
-					// Expected error
-					return false
-				}
-				info.Printf("Unable to copy authorized key to stdout: %v", err)
+		for range keys {
+			res := <-results
+			if res.err != nil && firstErr == nil {
+				firstErr = res.err
+			}
+			if _, err := io.Copy(&buf, res.data); err != nil {
+				info.Printf("Unable to read authorized key: %v", err)
 			}
 		}
+	}
 
-		return !lastPage
-	})
+	return &buf, firstErr
+}
+
+// printAuthorizedKeys writes the authorized keys for user from every
+// configured source to stdout, using the local cache (if enabled) for a
+// fresh hit or as a fallback when the live fetch fails.
+func printAuthorizedKeys(sourceURIs []string, user string) {
+	start := time.Now()
+	result := "success"
+	defer func() {
+		lookupDuration.Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(user, result).Inc()
+	}()
+
+	c := newCache()
+
+	// ca-mode changes the rendered output for the same sources/user, so it
+	// must be part of the cache key to avoid serving a stale format.
+	cacheSources := sourceURIs
+	if *caMode {
+		cacheSources = append(append([]string{}, sourceURIs...), fmt.Sprintf("ca-mode:%v", *caRestrictPrincipals))
+	}
 
+	if c.enabled() {
+		if body, ok := c.fresh(cacheSources, user); ok {
+			debug.Printf("Serving authorized keys for %s from cache", user)
+			cacheResult.WithLabelValues("hit").Set(1)
+			writeStdout(body)
+			return
+		}
+	}
+
+	buf, err := fetchAuthorizedKeys(sourceURIs, user)
 	if err != nil {
-		switch e := err.(type) {
-		case awserr.Error:
-			info.Fatalf("Unable to list authorized keys: %s, message: %s", e.Code(), e.Message())
-		default:
-			info.Fatalf("Error listing authorized keys: %v", e)
+		result = "error"
+
+		if c.enabled() {
+			if stale, ok := c.stale(cacheSources, user); ok {
+				info.Printf("Unable to refresh authorized keys for %s: %v; serving last known-good cache", user, err)
+				cacheResult.WithLabelValues("stale").Set(1)
+				result = "stale_cache"
+				writeStdout(stale)
+				return
+			}
 		}
 	}
+
+	if c.enabled() {
+		cacheResult.WithLabelValues("miss").Set(1)
+	}
+
+	if *caMode {
+		body := wrapCertAuthority(buf.Bytes(), user, *caRestrictPrincipals)
+		buf = bytes.NewBuffer(body)
+	}
+
+	writeStdout(buf.Bytes())
+
+	if err == nil && c.enabled() {
+		if err := c.store(cacheSources, user, buf.Bytes()); err != nil {
+			info.Printf("Unable to update authorized keys cache for %s: %v", user, err)
+		}
+	}
+}
+
+// writeStdout writes body to stdout, ignoring a broken pipe (expected
+// when sshd closes the command's stdout early).
+func writeStdout(body []byte) {
+	if _, err := os.Stdout.Write(body); err != nil && err != syscall.EPIPE {
+		info.Printf("Unable to write authorized keys to stdout: %v", err)
+	}
 }
 
 // readDefaultFlagFile reads the default flag file, see readFlagFile
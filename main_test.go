@@ -5,32 +5,33 @@ import (
 	"io"
 	"io/ioutil"
 	"testing"
-
-	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-type fakeS3 struct {
-	getObjectOutput s3.GetObjectOutput
+type fakeKeySource struct {
+	body string
+	err  error
 }
 
-func (f fakeS3) GetObject(i *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-	return &f.getObjectOutput, nil
+func (f fakeKeySource) List(prefix string) ([]string, error) {
+	return []string{prefix}, nil
 }
 
-func (f fakeS3) ListObjectsPages(i *s3.ListObjectsInput, fn func(p *s3.ListObjectsOutput, lastPage bool) (shouldContinue bool)) error {
-	return nil
+func (f fakeKeySource) Get(path string) (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return ioutil.NopCloser(bytes.NewBufferString(f.body)), nil
 }
 
 func TestReadAuthorizedKeyNewLine(t *testing.T) {
 	body := "asdfghjkl\n"
-	svc = &fakeS3{
-		getObjectOutput: s3.GetObjectOutput{
-			Body: ioutil.NopCloser(bytes.NewBufferString(body)),
-		},
-	}
-	keys := make(chan io.Reader)
-	go readAuthorizedKey("bucket", "/a/path", keys)
-	got, err := ioutil.ReadAll(<-keys)
+	results := make(chan keyResult)
+	go readAuthorizedKey(fakeKeySource{body: body}, "fake://bucket", "/a/path", "user", results)
+	res := <-results
+	if res.err != nil {
+		t.Errorf("Unexpected error: %v", res.err)
+	}
+	got, err := ioutil.ReadAll(res.data)
 	if err != nil {
 		t.Errorf("Unable to convert to string: %v", err)
 	}
@@ -42,14 +43,13 @@ func TestReadAuthorizedKeyNewLine(t *testing.T) {
 
 func TestReadAuthorizedKeyNoNewLine(t *testing.T) {
 	body := "asdfghjkl"
-	svc = &fakeS3{
-		getObjectOutput: s3.GetObjectOutput{
-			Body: ioutil.NopCloser(bytes.NewBufferString(body)),
-		},
-	}
-	keys := make(chan io.Reader)
-	go readAuthorizedKey("bucket", "/a/path", keys)
-	got, err := ioutil.ReadAll(<-keys)
+	results := make(chan keyResult)
+	go readAuthorizedKey(fakeKeySource{body: body}, "fake://bucket", "/a/path", "user", results)
+	res := <-results
+	if res.err != nil {
+		t.Errorf("Unexpected error: %v", res.err)
+	}
+	got, err := ioutil.ReadAll(res.data)
 	if err != nil {
 		t.Errorf("Unable to convert to string: %v", err)
 	}
@@ -58,3 +58,45 @@ func TestReadAuthorizedKeyNoNewLine(t *testing.T) {
 	}
 
 }
+
+func TestReadAuthorizedKeyAuthlog(t *testing.T) {
+	body := "asdfghjkl\n"
+
+	old := *authlog
+	*authlog = "/usr/local/bin/sshlogger.sh"
+	defer func() { *authlog = old }()
+
+	results := make(chan keyResult)
+	go readAuthorizedKey(fakeKeySource{body: body}, "fake://bucket", "/a/path", "user", results)
+	res := <-results
+	if res.err != nil {
+		t.Errorf("Unexpected error: %v", res.err)
+	}
+	got, err := ioutil.ReadAll(res.data)
+	if err != nil {
+		t.Errorf("Unable to convert to string: %v", err)
+	}
+	if !bytes.HasSuffix(got, []byte(body)) {
+		t.Errorf("Expected key to survive authlog wrapping: got: %v, wanted suffix: %v", string(got), body)
+	}
+	if !bytes.Contains(got, []byte("command=\"/usr/local/bin/sshlogger.sh user fake://bucket /a/path\" ")) {
+		t.Errorf("Expected command header in output, got: %v", string(got))
+	}
+}
+
+func TestReadAuthorizedKeyGetError(t *testing.T) {
+	wantErr := io.ErrUnexpectedEOF
+	results := make(chan keyResult)
+	go readAuthorizedKey(fakeKeySource{err: wantErr}, "fake://bucket", "/a/path", "user", results)
+	res := <-results
+	if res.err != wantErr {
+		t.Errorf("Got error: %v, Wanted: %v", res.err, wantErr)
+	}
+	got, err := ioutil.ReadAll(res.data)
+	if err != nil {
+		t.Errorf("Unable to convert to string: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Expected no data on error, got: %v", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// There is deliberately no -metrics-listen/serve mode: sshd runs sshauth
+// as a new per-connection AuthorizedKeysCommand process and waits for it
+// to exit before using its output, so a listener started here would never
+// live long enough to be scraped -- and blocking to keep it alive would
+// block the login it's serving. Exposing a pull endpoint would need a
+// separate, persistently-running daemon mode, which is out of scope here;
+// -pushgateway is the supported way to get per-login metrics out.
+var (
+	pushgateway = flag.String("pushgateway", "", "`address` of a Prometheus Pushgateway to push metrics to on exit")
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sshauth_requests_total",
+		Help: "Total number of sshauth lookups, by user and result.",
+	}, []string{"user", "result"})
+
+	s3GetTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sshauth_s3_get_total",
+		Help: "Total number of S3 GetObject calls, by result.",
+	}, []string{"result"})
+
+	s3ListTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sshauth_s3_list_total",
+		Help: "Total number of S3 ListObjects calls, by result.",
+	}, []string{"result"})
+
+	s3GetDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "sshauth_s3_get_duration_seconds",
+		Help: "Duration of S3 GetObject calls.",
+	})
+
+	lookupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "sshauth_lookup_duration_seconds",
+		Help: "Duration of an end-to-end authorized_keys lookup.",
+	})
+
+	cacheResult = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sshauth_cache_result",
+		Help: "Set to 1 for the cache outcome (hit, stale or miss) of the most recent lookup.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, s3GetTotal, s3ListTotal, s3GetDuration, lookupDuration, cacheResult)
+}
+
+// maybePushMetrics pushes this run's metrics to -pushgateway if set, since
+// sshauth is too short-lived for a scraper to reliably observe it.
+func maybePushMetrics(user string) {
+	if *pushgateway == "" {
+		return
+	}
+
+	err := push.New(*pushgateway, "sshauth").
+		Grouping("user", user).
+		Collector(requestsTotal).
+		Collector(s3GetTotal).
+		Collector(s3ListTotal).
+		Collector(s3GetDuration).
+		Collector(lookupDuration).
+		Collector(cacheResult).
+		Push()
+	if err != nil {
+		info.Printf("Unable to push metrics to %s: %v", *pushgateway, err)
+	}
+}
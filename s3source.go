@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	region               = flag.String("region", "", "AWS `region`, eg. eu-west-1")
+	anonymous            = flag.Bool("anonymous", false, "Use anonymous, unsigned requests, for buckets with a public-read bucket policy")
+	profile              = flag.String("profile", "", "Named shared-credentials `profile` to use")
+	assumeRoleARN        = flag.String("assume-role-arn", "", "STS role `arn` to assume before reading from S3")
+	assumeRoleExternalID = flag.String("assume-role-external-id", "", "External `id` to pass when assuming -assume-role-arn")
+)
+
+func init() {
+	RegisterKeySource("s3", newS3KeySource)
+}
+
+// s3er is the subset of the S3 API used by s3KeySource, kept as an
+// interface so it can be faked in tests.
+type s3er interface {
+	GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	ListObjectsPages(*s3.ListObjectsInput, func(p *s3.ListObjectsOutput, lastPage bool) (shouldContinue bool)) error
+}
+
+// s3KeySource reads authorized keys from an S3 bucket. It is registered
+// under the "s3" scheme and is also the default backend used when a
+// -source URI is given without a scheme.
+type s3KeySource struct {
+	svc    s3er
+	bucket string
+}
+
+// newS3KeySource builds an s3KeySource for a s3://bucket/prefix URI.
+func newS3KeySource(u *url.URL) (KeySource, string, error) {
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("s3 source %q is missing a bucket name", u.String())
+	}
+
+	cfg := aws.NewConfig().WithMaxRetries(10)
+	if *region != "" {
+		cfg = cfg.WithRegion(*region)
+		debug.Printf("Setting region: %s", *region)
+	}
+
+	switch {
+	case *anonymous:
+		cfg = cfg.WithCredentials(credentials.AnonymousCredentials)
+	case *profile != "":
+		cfg = cfg.WithCredentials(credentials.NewSharedCredentials("", *profile))
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create AWS session: %v", err)
+	}
+
+	if *assumeRoleARN != "" {
+		debug.Printf("Assuming role: %s", *assumeRoleARN)
+		sess = sess.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(sess, *assumeRoleARN, func(p *stscreds.AssumeRoleProvider) {
+				if *assumeRoleExternalID != "" {
+					p.ExternalID = aws.String(*assumeRoleExternalID)
+				}
+			}),
+		})
+	}
+
+	return &s3KeySource{
+		svc:    s3.New(sess),
+		bucket: u.Host,
+	}, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// List returns the keys found under prefix, skipping an object that
+// exactly matches prefix itself (S3 returns this when the prefix also
+// names an actual object, which is never a real per-user key).
+func (s *s3KeySource) List(prefix string) ([]string, error) {
+	var paths []string
+
+	params := &s3.ListObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	err := s.svc.ListObjectsPages(params, func(resp *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, content := range resp.Contents {
+			if *content.Key == prefix {
+				continue
+			}
+			paths = append(paths, *content.Key)
+		}
+		return !lastPage
+	})
+	if err != nil {
+		s3ListTotal.WithLabelValues("error").Inc()
+		switch e := err.(type) {
+		case awserr.Error:
+			return nil, fmt.Errorf("%s: %s", e.Code(), e.Message())
+		default:
+			return nil, e
+		}
+	}
+
+	s3ListTotal.WithLabelValues("success").Inc()
+	return paths, nil
+}
+
+// Get opens the object at key for reading.
+func (s *s3KeySource) Get(key string) (io.ReadCloser, error) {
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	start := time.Now()
+	resp, err := s.svc.GetObject(params)
+	s3GetDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		s3GetTotal.WithLabelValues("error").Inc()
+		switch e := err.(type) {
+		case awserr.Error:
+			return nil, fmt.Errorf("%s: %s", e.Code(), e.Message())
+		default:
+			return nil, err
+		}
+	}
+
+	s3GetTotal.WithLabelValues("success").Inc()
+	return resp.Body, nil
+}
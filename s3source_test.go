@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type fakeS3 struct {
+	getObjectOutput  s3.GetObjectOutput
+	listObjectsPages func(i *s3.ListObjectsInput, fn func(p *s3.ListObjectsOutput, lastPage bool) bool) error
+}
+
+func (f fakeS3) GetObject(i *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &f.getObjectOutput, nil
+}
+
+func (f fakeS3) ListObjectsPages(i *s3.ListObjectsInput, fn func(p *s3.ListObjectsOutput, lastPage bool) (shouldContinue bool)) error {
+	if f.listObjectsPages != nil {
+		return f.listObjectsPages(i, fn)
+	}
+	return nil
+}
+
+func TestS3KeySourceGet(t *testing.T) {
+	src := &s3KeySource{
+		bucket: "bucket",
+		svc: fakeS3{
+			getObjectOutput: s3.GetObjectOutput{
+				Body: ioutil.NopCloser(bytes.NewBufferString("asdfghjkl\n")),
+			},
+		},
+	}
+
+	rc, err := src.Get("/a/path")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read body: %v", err)
+	}
+	if string(got) != "asdfghjkl\n" {
+		t.Errorf("Got: %v, Wanted: %v", string(got), "asdfghjkl\n")
+	}
+}
+
+func TestS3KeySourceListSkipsSelf(t *testing.T) {
+	src := &s3KeySource{
+		bucket: "bucket",
+		svc: fakeS3{
+			listObjectsPages: func(i *s3.ListObjectsInput, fn func(p *s3.ListObjectsOutput, lastPage bool) bool) error {
+				fn(&s3.ListObjectsOutput{
+					Contents: []*s3.Object{
+						{Key: aws.String("prefix/user")},
+						{Key: aws.String("prefix/user/key1")},
+						{Key: aws.String("prefix/user/key2")},
+					},
+				}, true)
+				return nil
+			},
+		},
+	}
+
+	got, err := src.List("prefix/user")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+
+	want := []string{"prefix/user/key1", "prefix/user/key2"}
+	if len(got) != len(want) {
+		t.Fatalf("Got: %v, Wanted: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Got: %v, Wanted: %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	vaultAddr     = flag.String("vault-addr", "", "Vault server `address`, eg. https://127.0.0.1:8200. Falls back to VAULT_ADDR if not set")
+	vaultToken    = flag.String("vault-token", "", "Vault `token`, falls back to VAULT_TOKEN")
+	vaultMount    = flag.String("vault-mount", "secret", "Vault KV v2 `mount` to read keys from")
+	vaultPrefix   = flag.String("vault-prefix", "sshauth", "Vault key `prefix` within the mount")
+	vaultRole     = flag.String("vault-role", "", "Vault Kubernetes auth `role`, used with -vault-kubernetes-auth")
+	vaultRoleID   = flag.String("vault-role-id", "", "Vault AppRole `role_id`")
+	vaultSecretID = flag.String("vault-secret-id", "", "Vault AppRole `secret_id`")
+	vaultK8sAuth  = flag.Bool("vault-kubernetes-auth", false, "Authenticate to Vault using the Kubernetes auth method")
+	vaultK8sJWT   = flag.String("vault-kubernetes-jwt-path", "/var/run/secrets/kubernetes.io/serviceaccount/token", "`path` to the service account JWT used for Vault Kubernetes auth")
+)
+
+func init() {
+	RegisterKeySource("vault", newVaultKeySource)
+}
+
+// vaultKeySource reads authorized keys from a Vault KV v2 mount. A user's
+// keys are either a single secret at <prefix>/<user> with a newline
+// delimited "keys" field, or a set of secrets listed under that path,
+// each contributing one key.
+type vaultKeySource struct {
+	client *http.Client
+	addr   string
+	token  string
+	mount  string
+}
+
+// newVaultKeySource builds a vaultKeySource for a vault:// source URI. The
+// Vault address, mount and auth method are configured via the -vault-*
+// flags; an optional path on the URI (vault:///custom/prefix) overrides
+// -vault-prefix.
+func newVaultKeySource(u *url.URL) (KeySource, string, error) {
+	addr := *vaultAddr
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, "", fmt.Errorf("vault source requires -vault-addr (or VAULT_ADDR)")
+	}
+
+	src := &vaultKeySource{
+		client: &http.Client{Timeout: 10 * time.Second},
+		addr:   strings.TrimSuffix(addr, "/"),
+		mount:  *vaultMount,
+	}
+
+	token, err := src.authenticate()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to authenticate to vault: %v", err)
+	}
+	src.token = token
+
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix == "" {
+		prefix = *vaultPrefix
+	}
+
+	return src, prefix, nil
+}
+
+// authenticate resolves a Vault token, preferring (in order) -vault-token,
+// VAULT_TOKEN, AppRole login, and Kubernetes auth.
+func (v *vaultKeySource) authenticate() (string, error) {
+	if *vaultToken != "" {
+		return *vaultToken, nil
+	}
+
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if *vaultRoleID != "" {
+		return v.loginAppRole(*vaultRoleID, *vaultSecretID)
+	}
+
+	if *vaultK8sAuth {
+		jwt, err := ioutil.ReadFile(*vaultK8sJWT)
+		if err != nil {
+			return "", fmt.Errorf("unable to read kubernetes service account jwt: %v", err)
+		}
+		return v.loginKubernetes(*vaultRole, strings.TrimSpace(string(jwt)))
+	}
+
+	return "", fmt.Errorf("no vault credentials configured: set -vault-token, -vault-role-id, or -vault-kubernetes-auth")
+}
+
+// loginAppRole authenticates via the AppRole auth method and returns the
+// resulting client token.
+func (v *vaultKeySource) loginAppRole(roleID, secretID string) (string, error) {
+	return v.login("auth/approle/login", map[string]string{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+}
+
+// loginKubernetes authenticates via the Kubernetes auth method and returns
+// the resulting client token.
+func (v *vaultKeySource) loginKubernetes(role, jwt string) (string, error) {
+	return v.login("auth/kubernetes/login", map[string]string{
+		"role": role,
+		"jwt":  jwt,
+	})
+}
+
+// login posts to a Vault auth login endpoint and returns the issued
+// client token.
+func (v *vaultKeySource) login(loginPath string, body map[string]string) (string, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, v.addr+"/v1/"+loginPath, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault login failed with status %s", resp.Status)
+	}
+
+	var out struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("unable to decode vault login response: %v", err)
+	}
+
+	return out.Auth.ClientToken, nil
+}
+
+// List returns the secret paths found under prefix. If prefix itself has
+// no sub-paths, it is returned as the single path to Get, matching the
+// "one secret per user" layout.
+func (v *vaultKeySource) List(prefix string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+v.mount+"/metadata/"+prefix+"?list=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return []string{prefix}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault list of %q failed with status %s", prefix, resp.Status)
+	}
+
+	var out struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("unable to decode vault list response: %v", err)
+	}
+
+	paths := make([]string, 0, len(out.Data.Keys))
+	for _, key := range out.Data.Keys {
+		paths = append(paths, strings.TrimSuffix(prefix+"/"+key, "/"))
+	}
+
+	return paths, nil
+}
+
+// Get fetches the secret at path and returns its "keys" field, the
+// newline delimited public key(s) stored there. A missing secret (404) is
+// not an error: it means the user simply has no key at that path, the
+// same as an empty prefix listing in the S3 backend, and must be kept
+// distinguishable from a genuine Vault failure so it doesn't trigger the
+// stale-cache fallback.
+func (v *vaultKeySource) Get(path string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+v.mount+"/data/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault get of %q failed with status %s", path, resp.Status)
+	}
+
+	var out struct {
+		Data struct {
+			Data struct {
+				Keys string `json:"keys"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("unable to decode vault secret for %q: %v", path, err)
+	}
+
+	return ioutil.NopCloser(strings.NewReader(out.Data.Data.Keys)), nil
+}
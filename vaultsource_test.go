@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newFakeVaultServer(t *testing.T, wantToken string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != wantToken {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v1/secret/metadata/sshauth/alice" && r.URL.Query().Get("list") == "true":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"keys": []string{"key1", "key2"},
+				},
+			})
+		case r.URL.Path == "/v1/secret/metadata/sshauth/bob" && r.URL.Query().Get("list") == "true":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/v1/secret/data/sshauth/alice/key1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{"keys": "ssh-ed25519 AAAA... alice-laptop\n"},
+				},
+			})
+		case r.URL.Path == "/v1/secret/data/sshauth/alice/key2":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{"keys": "ssh-ed25519 BBBB... alice-phone\n"},
+				},
+			})
+		case r.URL.Path == "/v1/secret/data/sshauth/bob":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{"keys": "ssh-ed25519 CCCC... bob\n"},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVaultKeySourceListMultipleKeys(t *testing.T) {
+	srv := newFakeVaultServer(t, "testtoken")
+	defer srv.Close()
+
+	src := &vaultKeySource{client: srv.Client(), addr: srv.URL, token: "testtoken", mount: "secret"}
+
+	paths, err := src.List("sshauth/alice")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+
+	want := []string{"sshauth/alice/key1", "sshauth/alice/key2"}
+	if len(paths) != len(want) {
+		t.Fatalf("Got: %v, Wanted: %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("Got: %v, Wanted: %v", paths, want)
+		}
+	}
+}
+
+func TestVaultKeySourceListSingleSecret(t *testing.T) {
+	srv := newFakeVaultServer(t, "testtoken")
+	defer srv.Close()
+
+	src := &vaultKeySource{client: srv.Client(), addr: srv.URL, token: "testtoken", mount: "secret"}
+
+	paths, err := src.List("sshauth/bob")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+
+	if len(paths) != 1 || paths[0] != "sshauth/bob" {
+		t.Errorf("Got: %v, Wanted: %v", paths, []string{"sshauth/bob"})
+	}
+}
+
+func TestVaultKeySourceGet(t *testing.T) {
+	srv := newFakeVaultServer(t, "testtoken")
+	defer srv.Close()
+
+	src := &vaultKeySource{client: srv.Client(), addr: srv.URL, token: "testtoken", mount: "secret"}
+
+	rc, err := src.Get("sshauth/alice/key1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read body: %v", err)
+	}
+
+	want := "ssh-ed25519 AAAA... alice-laptop\n"
+	if string(got) != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+}
+
+func TestVaultKeySourceGetMissingSecretIsEmpty(t *testing.T) {
+	srv := newFakeVaultServer(t, "testtoken")
+	defer srv.Close()
+
+	src := &vaultKeySource{client: srv.Client(), addr: srv.URL, token: "testtoken", mount: "secret"}
+
+	// "carol" has no sub-paths (404 from List) and no secret at the
+	// synthesized single-secret path either (404 from Get) -- a keyless
+	// user, not a Vault failure.
+	paths, err := src.List("sshauth/carol")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "sshauth/carol" {
+		t.Fatalf("Got: %v, Wanted: %v", paths, []string{"sshauth/carol"})
+	}
+
+	rc, err := src.Get(paths[0])
+	if err != nil {
+		t.Fatalf("Get returned error for a missing secret: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read body: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no key data for a missing secret, got: %q", got)
+	}
+}
+
+func TestVaultKeySourceGetBadToken(t *testing.T) {
+	srv := newFakeVaultServer(t, "testtoken")
+	defer srv.Close()
+
+	src := &vaultKeySource{client: srv.Client(), addr: srv.URL, token: "wrong", mount: "secret"}
+
+	if _, err := src.Get("sshauth/alice/key1"); err == nil {
+		t.Errorf("expected an error for a rejected token")
+	}
+}